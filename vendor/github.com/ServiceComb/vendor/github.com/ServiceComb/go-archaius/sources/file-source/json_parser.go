@@ -0,0 +1,62 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func init() {
+	RegisterParser(".json", jsonParser{})
+}
+
+//jsonParser parses JSON content into a flattened configuration map.
+type jsonParser struct{}
+
+func (jsonParser) Parse(content []byte) (map[string]interface{}, error) {
+	raw := map[string]interface{}{}
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("json unmarshal failed, %s", err)
+	}
+
+	return retrieveMapItems("", raw), nil
+}
+
+//retrieveMapItems flattens a nested map[string]interface{}, as produced by
+//encoding/json, into dotted keys the same way retrieveItems does for YAML.
+func retrieveMapItems(prefix string, subItems map[string]interface{}) map[string]interface{} {
+	if prefix != "" {
+		prefix += "."
+	}
+
+	result := map[string]interface{}{}
+
+	for key, value := range subItems {
+		sub, isMap := value.(map[string]interface{})
+		if isMap {
+			subResult := retrieveMapItems(prefix+key, sub)
+			for k, v := range subResult {
+				result[k] = v
+			}
+		} else {
+			result[prefix+key] = value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import "path/filepath"
+
+//FileSourceOptions controls how AddFileSource walks and filters a
+//directory file source. The zero value keeps the historical behavior:
+//a single directory level, every file considered regardless of
+//extension.
+type FileSourceOptions struct {
+	//IncludeExt, when non-empty, restricts file sources to files whose
+	//extension (e.g. ".yaml") matches one of these entries. Entries are
+	//also matched as shell globs against the file's base name, so
+	//"*.cfg.yaml" is valid.
+	IncludeExt []string
+	//ExcludeExt excludes files whose extension or base name matches one
+	//of these glob patterns. Exclude wins over include.
+	ExcludeExt []string
+	//IncludeDirs, when non-empty, restricts recursive traversal to
+	//sub-directories whose base name matches one of these glob patterns.
+	IncludeDirs []string
+	//ExcludeDirs prunes sub-directories whose base name matches one of
+	//these glob patterns; matching directories are not descended into.
+	ExcludeDirs []string
+	//Recursive enables walking into sub-directories. When false (the
+	//default) only the top-level directory is scanned, matching the
+	//historical behavior.
+	Recursive bool
+	//IgnoreWarn suppresses the warning normally logged when a watched
+	//directory emits a change event for a path that the include/exclude
+	//filters reject.
+	IgnoreWarn bool
+}
+
+//mergeFileSourceOptions returns the first option in opts, or the zero
+//value FileSourceOptions if none was supplied. AddFileSource accepts
+//opts as a variadic argument purely so existing callers that pass none
+//keep compiling unchanged.
+func mergeFileSourceOptions(opts []FileSourceOptions) FileSourceOptions {
+	if len(opts) == 0 {
+		return FileSourceOptions{}
+	}
+
+	return opts[0]
+}
+
+//allowsExt reports whether fileName passes the IncludeExt/ExcludeExt
+//filters of opts.
+func (opts FileSourceOptions) allowsExt(fileName string) bool {
+	base := filepath.Base(fileName)
+	ext := fileExt(fileName)
+
+	if matchesAny(opts.ExcludeExt, base) || matchesAny(opts.ExcludeExt, ext) {
+		return false
+	}
+
+	if len(opts.IncludeExt) == 0 {
+		return true
+	}
+
+	return matchesAny(opts.IncludeExt, base) || matchesAny(opts.IncludeExt, ext)
+}
+
+//allowsDir reports whether the directory named dirName passes the
+//IncludeDirs/ExcludeDirs filters of opts.
+func (opts FileSourceOptions) allowsDir(dirName string) bool {
+	base := filepath.Base(dirName)
+
+	if matchesAny(opts.ExcludeDirs, base) {
+		return false
+	}
+
+	if len(opts.IncludeDirs) == 0 {
+		return true
+	}
+
+	return matchesAny(opts.IncludeDirs, base)
+}
+
+//matchesAny reports whether name matches any of the shell glob patterns.
+//A pattern that fails to compile is treated as a literal, exact match.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		} else if pattern == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v2"
+)
+
+//Parser converts the raw bytes of a configuration file into a flattened
+//map[string]interface{} keyed by dotted path, the same shape yamlParser
+//produces for YAML files.
+type Parser interface {
+	Parse(content []byte) (map[string]interface{}, error)
+}
+
+var (
+	parserLock sync.RWMutex
+	//parsers maps a lower-cased file extension (including the leading dot)
+	//to the Parser responsible for decoding it.
+	parsers = map[string]Parser{
+		".yaml": yamlParser{},
+		".yml":  yamlParser{},
+	}
+)
+
+//RegisterParser registers a Parser for the given file extension, allowing
+//external code to plug in support for additional configuration formats.
+//ext must include the leading dot, e.g. ".ini". Registering a Parser for
+//an extension that is already registered overwrites the previous one.
+func RegisterParser(ext string, p Parser) {
+	parserLock.Lock()
+	defer parserLock.Unlock()
+
+	parsers[strings.ToLower(ext)] = p
+}
+
+//GetParser returns the Parser registered for the given file extension, or
+//false if no parser is registered for it.
+func GetParser(ext string) (Parser, bool) {
+	parserLock.RLock()
+	defer parserLock.RUnlock()
+
+	p, ok := parsers[strings.ToLower(ext)]
+	return p, ok
+}
+
+//parseFile reads fileName from disk and runs it through the Parser
+//registered for its extension.
+func parseFile(fileName string) (map[string]interface{}, error) {
+	ext := fileExt(fileName)
+	parser, ok := GetParser(ext)
+	if !ok {
+		return nil, fmt.Errorf("no parser registered for file type [%s]", ext)
+	}
+
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap, err := parser.Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse [%s] file, %s", fileName, err)
+	}
+
+	return configMap, nil
+}
+
+//yamlParser parses YAML content into a flattened configuration map.
+type yamlParser struct{}
+
+func (yamlParser) Parse(content []byte) (map[string]interface{}, error) {
+	ss := yaml.MapSlice{}
+	err := yaml.Unmarshal(content, &ss)
+	if err != nil {
+		return nil, fmt.Errorf("yaml unmarshal failed, %s", err)
+	}
+
+	return retrieveItems("", ss), nil
+}
+
+//retrieveItems flattens a yaml.MapSlice into dotted keys, e.g.
+//{a: {b: 1}} -> {"a.b": 1}. The result is a map, so the key order of the
+//source document is not kept.
+func retrieveItems(prefix string, subItems yaml.MapSlice) map[string]interface{} {
+	if prefix != "" {
+		prefix += "."
+	}
+
+	result := map[string]interface{}{}
+
+	for _, item := range subItems {
+		//If there are sub-items existing
+		_, isSlice := item.Value.(yaml.MapSlice)
+		if isSlice {
+			subResult := retrieveItems(prefix+item.Key.(string), item.Value.(yaml.MapSlice))
+			for k, v := range subResult {
+				result[k] = v
+			}
+		} else {
+			result[prefix+item.Key.(string)] = item.Value
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import "testing"
+
+func TestJSONParserFlattensNestedKeys(t *testing.T) {
+	p := jsonParser{}
+
+	configMap, err := p.Parse([]byte(`{"a":{"b":1,"c":{"d":"x"}},"e":true}`))
+	if err != nil {
+		t.Fatalf("unexpected error parsing json: %s", err)
+	}
+
+	cases := map[string]interface{}{
+		"a.b":   float64(1),
+		"a.c.d": "x",
+		"e":     true,
+	}
+	for key, want := range cases {
+		got, ok := configMap[key]
+		if !ok {
+			t.Fatalf("expected key %q in parsed config, got %v", key, configMap)
+		}
+		if got != want {
+			t.Errorf("key %q: expected %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestTOMLParserFlattensNestedKeys(t *testing.T) {
+	p := tomlParser{}
+
+	content := `
+e = true
+
+[a]
+b = 1
+
+[a.c]
+d = "x"
+`
+	configMap, err := p.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error parsing toml: %s", err)
+	}
+
+	cases := map[string]interface{}{
+		"a.b":   int64(1),
+		"a.c.d": "x",
+		"e":     true,
+	}
+	for key, want := range cases {
+		got, ok := configMap[key]
+		if !ok {
+			t.Fatalf("expected key %q in parsed config, got %v", key, configMap)
+		}
+		if got != want {
+			t.Errorf("key %q: expected %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestPropertiesParserKeepsDottedKeys(t *testing.T) {
+	p := propertiesParser{}
+
+	content := "a.b=1\na.c.d=x\ne=true\n"
+	configMap, err := p.Parse([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error parsing properties: %s", err)
+	}
+
+	cases := map[string]interface{}{
+		"a.b":   "1",
+		"a.c.d": "x",
+		"e":     "true",
+	}
+	for key, want := range cases {
+		got, ok := configMap[key]
+		if !ok {
+			t.Fatalf("expected key %q in parsed config, got %v", key, configMap)
+		}
+		if got != want {
+			t.Errorf("key %q: expected %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestRegisterParserAndGetParser(t *testing.T) {
+	const ext = ".test-ini"
+
+	if _, ok := GetParser(ext); ok {
+		t.Fatalf("expected no parser registered for %q before RegisterParser", ext)
+	}
+
+	RegisterParser(ext, jsonParser{})
+
+	p, ok := GetParser(ext)
+	if !ok {
+		t.Fatalf("expected a parser to be registered for %q", ext)
+	}
+	if _, isJSONParser := p.(jsonParser); !isJSONParser {
+		t.Errorf("expected registered parser to be a jsonParser, got %T", p)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"fmt"
+
+	"github.com/magiconair/properties"
+)
+
+func init() {
+	RegisterParser(".properties", propertiesParser{})
+}
+
+//propertiesParser parses Java-style .properties content into a flattened
+//configuration map. Properties files are naturally flat (dotted keys are
+//just part of the key name), so no further flattening is required.
+type propertiesParser struct{}
+
+func (propertiesParser) Parse(content []byte) (map[string]interface{}, error) {
+	p, err := properties.Load(content, properties.UTF8)
+	if err != nil {
+		return nil, fmt.Errorf("properties unmarshal failed, %s", err)
+	}
+
+	configMap := make(map[string]interface{}, len(p.Keys()))
+	for _, key := range p.Keys() {
+		value, _ := p.Get(key)
+		configMap[key] = value
+	}
+
+	return configMap, nil
+}
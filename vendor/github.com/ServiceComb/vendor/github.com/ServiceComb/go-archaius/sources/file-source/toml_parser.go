@@ -0,0 +1,39 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+func init() {
+	RegisterParser(".toml", tomlParser{})
+}
+
+//tomlParser parses TOML content into a flattened configuration map.
+type tomlParser struct{}
+
+func (tomlParser) Parse(content []byte) (map[string]interface{}, error) {
+	raw := map[string]interface{}{}
+	if err := toml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("toml unmarshal failed, %s", err)
+	}
+
+	return retrieveMapItems("", raw), nil
+}
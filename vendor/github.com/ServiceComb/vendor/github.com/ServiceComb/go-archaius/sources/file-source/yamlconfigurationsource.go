@@ -25,12 +25,14 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ServiceComb/go-archaius/core"
 	"github.com/ServiceComb/go-archaius/lager"
 	"github.com/fsnotify/fsnotify"
-	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -39,6 +41,11 @@ const (
 	fileSourcePriority    = 4
 	//DefaultFilePriority is a variable of type string
 	DefaultFilePriority = 0
+	//defaultDebounceInterval is how long watchFile waits, after the last
+	//fsnotify event for a path, before reloading it. This coalesces the
+	//burst of WRITE/CHMOD/CREATE events most editors fire per save into
+	//a single reload.
+	defaultDebounceInterval = 200 * time.Millisecond
 )
 
 //FileSourceTypes is a string
@@ -62,8 +69,15 @@ type ConfigInfo struct {
 type yamlConfigurationSource struct {
 	Configurations map[string]*ConfigInfo
 	files          []file
-	watchPool      *watch
-	filelock       sync.Mutex
+	//rawConfigs holds each tracked file's own flattened configuration,
+	//keyed by file path, independent of which file currently "wins" a
+	//given key in Configurations. GetMergedConfigurations and
+	//Reprioritize recompute the effective view from this.
+	rawConfigs       map[string]map[string]interface{}
+	dirOptions       map[string]dirSource
+	watchPool        *watch
+	debounceInterval time.Duration
+	filelock         sync.Mutex
 	sync.RWMutex
 }
 
@@ -72,19 +86,44 @@ type file struct {
 	priority uint32
 }
 
+//dirSource is what AddFileSource recorded for a directory file source:
+//the priority newly-discovered files under it should be registered with,
+//and the filtering/recursion options that govern which ones are.
+type dirSource struct {
+	priority uint32
+	opts     FileSourceOptions
+}
+
 type watch struct {
 	//files   []string
 	watcher    *fsnotify.Watcher
 	callback   core.DynamicConfigCallback
 	fileSource *yamlConfigurationSource
+	//debounceInterval is how long to wait, per file path, for events to
+	//stop arriving before reloading. See defaultDebounceInterval.
+	debounceInterval time.Duration
+	//pending holds, per file path, the timer counting down to the next
+	//reload; a fresh event for that path resets it.
+	pending map[string]*time.Timer
+	//symlinkWatch maps a tracked file's own literal parent directory to
+	//the tracked files under it that resolve through at least one
+	//symlink. Kubernetes projects ConfigMap volumes this way: both the
+	//mounted file and the "..data" entry it resolves through are
+	//symlinks, and atomically swapping "..data" fires an fsnotify event
+	//against "..data" itself - a sibling of the tracked file, in the
+	//very same literal directory that's already being watched, not
+	//against anything under whatever directory the chain resolved to
+	//when the watch was first set up. So instead of resolving once and
+	//watching a target directory that goes stale the moment the symlink
+	//is repointed, every event in such a directory re-resolves each
+	//symlinked file tracked there.
+	symlinkWatch map[string][]string
 	sync.RWMutex
 }
 
 var _ core.ConfigSource = &yamlConfigurationSource{}
 var _ FileSource = &yamlConfigurationSource{}
 
-var fileConfigSource *yamlConfigurationSource
-
 /*
 	accepts files and directories as file-source
   		1. Directory: all yaml files considered as file source
@@ -96,20 +135,80 @@ var fileConfigSource *yamlConfigurationSource
 //FileSource is a interface
 type FileSource interface {
 	core.ConfigSource
-	AddFileSource(filePath string, priority uint32) error
+	AddFileSource(filePath string, priority uint32, opts ...FileSourceOptions) error
+	//SetDebounceInterval overrides how long the file watcher waits for
+	//events on a path to settle before reloading it. May be called
+	//before or after DynamicConfigHandler.
+	SetDebounceInterval(interval time.Duration)
+	//GetMergedConfigurations returns the effective configuration view:
+	//for every key tracked across all file sources, the value from the
+	//highest-priority file that defines it (ties broken by file path).
+	GetMergedConfigurations() map[string]interface{}
+	//Reprioritize changes filePath's priority and recomputes the
+	//effective value of every key, firing callback events for any key
+	//whose winning file changed as a result.
+	Reprioritize(filePath string, newPriority uint32) error
 }
 
-//NewYamlConfigurationSource creates new yaml configuration
-func NewYamlConfigurationSource() FileSource {
-	if fileConfigSource == nil {
-		fileConfigSource = new(yamlConfigurationSource)
-		fileConfigSource.files = make([]file, 0)
+//Option configures a FileSource at construction time, passed to
+//NewFileSource.
+type Option func(*yamlConfigurationSource)
+
+//WithDebounceInterval sets the interval the file watcher debounces
+//fsnotify events by, before DynamicConfigHandler is ever called.
+func WithDebounceInterval(interval time.Duration) Option {
+	return func(fSource *yamlConfigurationSource) {
+		fSource.debounceInterval = interval
+	}
+}
+
+//NewFileSource creates a fresh, independent FileSource. Unlike the
+//now-removed process-wide singleton, every call returns its own
+//Configurations map, files list and watch pool, so multiple sources
+//(tests, or multiple tenants embedding archaius in one process) can run
+//side by side without clobbering each other.
+func NewFileSource(opts ...Option) FileSource {
+	fSource := &yamlConfigurationSource{
+		files:            make([]file, 0),
+		rawConfigs:       make(map[string]map[string]interface{}),
+		dirOptions:       make(map[string]dirSource),
+		debounceInterval: defaultDebounceInterval,
+	}
+
+	for _, opt := range opts {
+		opt(fSource)
 	}
 
-	return fileConfigSource
+	return fSource
+}
+
+var (
+	defaultSourceOnce sync.Once
+	defaultSource     FileSource
+)
+
+//Default returns the process-wide FileSource that NewYamlConfigurationSource
+//used to return, for callers that have not migrated to NewFileSource yet.
+func Default() FileSource {
+	defaultSourceOnce.Do(func() {
+		defaultSource = NewFileSource()
+	})
+
+	return defaultSource
 }
 
-func (fSource *yamlConfigurationSource) AddFileSource(p string, priority uint32) error {
+//NewYamlConfigurationSource is kept for backward compatibility; it
+//returns the shared Default() source. New code should call NewFileSource
+//to get an independent instance instead.
+func NewYamlConfigurationSource() FileSource {
+	return Default()
+}
+
+//AddFileSource adds a file or directory as a configuration source.
+//opts is optional; when supplied, its first element configures
+//extension/directory filtering and recursive traversal for directory
+//sources (see FileSourceOptions). It has no effect on a single file.
+func (fSource *yamlConfigurationSource) AddFileSource(p string, priority uint32, opts ...FileSourceOptions) error {
 	path, err := filepath.Abs(p)
 	if err != nil {
 		return err
@@ -127,11 +226,14 @@ func (fSource *yamlConfigurationSource) AddFileSource(p string, priority uint32)
 		return nil
 	}
 
+	opt := mergeFileSourceOptions(opts)
+
 	fileType := fileType(fs)
 	switch fileType {
 	case Directory:
-		// handle Directory input. Include all yaml files as file source.
-		err := fSource.handleDirectory(fs, priority)
+		// handle Directory input. Include all matching files as file source.
+		fSource.setDirOptions(path, priority, opt)
+		err := fSource.handleDirectory(fs, priority, opt)
 		if err != nil {
 			lager.Logger.Errorf(err, "Failed to handle directory [%s]", path)
 			return err
@@ -149,12 +251,54 @@ func (fSource *yamlConfigurationSource) AddFileSource(p string, priority uint32)
 	}
 
 	if fSource.watchPool != nil {
-		fSource.watchPool.AddWatchFile(path)
+		if fileType == Directory {
+			for _, dir := range fSource.watchedDirs(path, opt) {
+				fSource.watchPool.AddWatchDir(dir)
+			}
+		} else {
+			fSource.watchPool.AddWatchFile(path)
+		}
 	}
 
 	return nil
 }
 
+//setDirOptions records the priority and FileSourceOptions a directory
+//source was added with, so later fsnotify events under it can be
+//filtered, and newly discovered files registered, the same way.
+func (fSource *yamlConfigurationSource) setDirOptions(path string, priority uint32, opts FileSourceOptions) {
+	fSource.Lock()
+	defer fSource.Unlock()
+
+	if fSource.dirOptions == nil {
+		fSource.dirOptions = make(map[string]dirSource)
+	}
+
+	fSource.dirOptions[path] = dirSource{priority: priority, opts: opts}
+}
+
+//dirOptionsFor returns the priority and FileSourceOptions registered for
+//the directory that contains filePath, walking up to the nearest
+//registered ancestor. ok is false if filePath is not under any
+//registered directory source.
+func (fSource *yamlConfigurationSource) dirOptionsFor(filePath string) (opts FileSourceOptions, priority uint32, dir string, ok bool) {
+	fSource.Lock()
+	defer fSource.Unlock()
+
+	dir = filepath.Dir(filePath)
+	for {
+		if ds, found := fSource.dirOptions[dir]; found {
+			return ds.opts, ds.priority, dir, true
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return FileSourceOptions{}, 0, "", false
+		}
+		dir = parent
+	}
+}
+
 func (fSource *yamlConfigurationSource) isFileSrcExist(filePath string) bool {
 	var exist bool
 	for _, file := range fSource.files {
@@ -183,36 +327,75 @@ func fileType(fs *os.File) FileSourceTypes {
 	return InvalidFileType
 }
 
-func (fSource *yamlConfigurationSource) handleDirectory(dir *os.File, priority uint32) error {
+func (fSource *yamlConfigurationSource) handleDirectory(dir *os.File, priority uint32, opts FileSourceOptions) error {
+	for _, subDir := range fSource.watchedDirs(dir.Name(), opts) {
+		entries, err := ioutil.ReadDir(subDir)
+		if err != nil {
+			lager.Logger.Errorf(err, "failed to read directory [%s] contents", subDir)
+			continue
+		}
 
-	filesInfo, err := dir.Readdir(-1)
-	if err != nil {
-		return errors.New("failed to read Directory contents")
+		for _, fileInfo := range entries {
+			if fileInfo.IsDir() {
+				continue
+			}
+
+			filePath := filepath.Join(subDir, fileInfo.Name())
+			if !opts.allowsExt(filePath) {
+				continue
+			}
+
+			fs, err := os.Open(filePath)
+			if err != nil {
+				lager.Logger.Errorf(err, "error in file open for %s file", err.Error())
+				continue
+			}
+
+			err = fSource.handleFile(fs, priority)
+			if err != nil {
+				lager.Logger.Errorf(err, "error processing %s file source handler with error : %s ", fs.Name(),
+					err.Error())
+			}
+			fs.Close()
+		}
 	}
 
-	for _, fileInfo := range filesInfo {
-		filePath := filepath.Join(dir.Name(), fileInfo.Name())
+	return nil
+}
 
-		fs, err := os.Open(filePath)
-		if err != nil {
-			lager.Logger.Errorf(err, "error in file open for %s file", err.Error())
+//watchedDirs returns root and, if opts.Recursive is set, every
+//sub-directory under it that passes the IncludeDirs/ExcludeDirs
+//filters. Excluded directories are pruned - their children are never
+//visited.
+func (fSource *yamlConfigurationSource) watchedDirs(root string, opts FileSourceOptions) []string {
+	dirs := []string{root}
+	if !opts.Recursive {
+		return dirs
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		lager.Logger.Errorf(err, "failed to read directory [%s] contents", root)
+		return dirs
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
 			continue
 		}
 
-		err = fSource.handleFile(fs, priority)
-		if err != nil {
-			lager.Logger.Errorf(err, "error processing %s file source handler with error : %s ", fs.Name(),
-				err.Error())
+		if !opts.allowsDir(entry.Name()) {
+			continue
 		}
-		fs.Close()
 
+		dirs = append(dirs, fSource.watchedDirs(filepath.Join(root, entry.Name()), opts)...)
 	}
 
-	return nil
+	return dirs
 }
 
 func (fSource *yamlConfigurationSource) handleFile(file *os.File, priority uint32) error {
-	config, err := fileConfigSource.pullYamlFileConfig(file.Name())
+	config, err := fSource.pullFileConfig(file.Name())
 	if err != nil {
 		return fmt.Errorf("failed to pull configurations from [%s] file, %s", file.Name(), err)
 	}
@@ -222,6 +405,8 @@ func (fSource *yamlConfigurationSource) handleFile(file *os.File, priority uint3
 		return fmt.Errorf("failed to handle priority of [%s], %s", file.Name(), err)
 	}
 
+	fSource.setRawConfig(file.Name(), config)
+
 	events := fSource.compareUpdate(config, file.Name())
 	if fSource.watchPool != nil && fSource.watchPool.callback != nil { // if file source already added and try to add
 		for _, e := range events {
@@ -232,50 +417,149 @@ func (fSource *yamlConfigurationSource) handleFile(file *os.File, priority uint3
 	return nil
 }
 
+//handlePriority upserts filePath's priority: if filePath is already
+//tracked its priority is updated in place, otherwise a new entry is
+//appended. This must be an upsert by filePath alone - matching on
+//(filePath, priority) together, as a naive equality check would, can
+//never find the stale entry when the priority itself is what changed,
+//leaving two entries for the same file and the old priority in effect.
 func (fSource *yamlConfigurationSource) handlePriority(filePath string, priority uint32) error {
 	fSource.Lock()
-	newFilePriority := make([]file, 0)
-	var prioritySet bool
-	for _, f := range fSource.files {
+	defer fSource.Unlock()
 
-		if f.filePath == filePath && f.priority == priority {
-			prioritySet = true
-			newFilePriority = append(newFilePriority, file{
-				filePath: filePath,
-				priority: priority,
-			})
+	for i, f := range fSource.files {
+		if f.filePath == filePath {
+			fSource.files[i].priority = priority
+			return nil
 		}
-		newFilePriority = append(newFilePriority, f)
 	}
 
-	if !prioritySet {
-		newFilePriority = append(newFilePriority, file{
-			filePath: filePath,
-			priority: priority,
-		})
+	fSource.files = append(fSource.files, file{
+		filePath: filePath,
+		priority: priority,
+	})
+
+	return nil
+}
+
+//setRawConfig records filePath's own flattened configuration, used by
+//GetMergedConfigurations and Reprioritize to recompute the effective
+//view independent of whatever currently won in Configurations.
+func (fSource *yamlConfigurationSource) setRawConfig(filePath string, conf map[string]interface{}) {
+	fSource.Lock()
+	defer fSource.Unlock()
+
+	if fSource.rawConfigs == nil {
+		fSource.rawConfigs = make(map[string]map[string]interface{})
 	}
 
-	fSource.files = newFilePriority
-	fSource.Unlock()
+	fSource.rawConfigs[filePath] = conf
+}
 
-	return nil
+//GetMergedConfigurations returns the effective configuration view: for
+//every key tracked across all file sources, the value from the
+//highest-priority file that defines it. Priority numbers are smaller-is-
+//higher, matching compareUpdate; ties are broken deterministically by
+//file path rather than by fSource.files iteration order.
+func (fSource *yamlConfigurationSource) GetMergedConfigurations() map[string]interface{} {
+	fSource.Lock()
+	defer fSource.Unlock()
+
+	priorities := make(map[string]uint32, len(fSource.files))
+	for _, f := range fSource.files {
+		priorities[f.filePath] = f.priority
+	}
+
+	paths := make([]string, 0, len(fSource.rawConfigs))
+	for p := range fSource.rawConfigs {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	type winner struct {
+		priority uint32
+		filePath string
+		value    interface{}
+	}
+	winners := make(map[string]winner)
+
+	for _, p := range paths {
+		priority := priorities[p]
+		for key, value := range fSource.rawConfigs[p] {
+			cur, ok := winners[key]
+			if !ok || priority < cur.priority || (priority == cur.priority && p < cur.filePath) {
+				winners[key] = winner{priority: priority, filePath: p, value: value}
+			}
+		}
+	}
+
+	merged := make(map[string]interface{}, len(winners))
+	for key, w := range winners {
+		merged[key] = w.value
+	}
+
+	return merged
 }
 
-func (fSource *yamlConfigurationSource) pullYamlFileConfig(fileName string) (map[string]interface{}, error) {
-	configMap := make(map[string]interface{})
-	yamlContent, err := ioutil.ReadFile(fileName)
+//Reprioritize changes filePath's priority and re-runs compareUpdate for
+//every tracked file, in priority order, so that callbacks fire for any
+//key whose winning file changed.
+func (fSource *yamlConfigurationSource) Reprioritize(filePath string, newPriority uint32) error {
+	path, err := filepath.Abs(filePath)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	ss := yaml.MapSlice{}
-	err = yaml.Unmarshal([]byte(yamlContent), &ss)
-	if err != nil {
-		return nil, fmt.Errorf("yaml unmarshal [%s] failed, %s", fileName, err)
+	if err := fSource.handlePriority(path, newPriority); err != nil {
+		return err
 	}
-	configMap = retrieveItems("", ss)
 
-	return configMap, nil
+	fSource.Lock()
+	files := make([]file, len(fSource.files))
+	copy(files, fSource.files)
+	rawConfigs := make(map[string]map[string]interface{}, len(fSource.rawConfigs))
+	for p, conf := range fSource.rawConfigs {
+		rawConfigs[p] = conf
+	}
+	fSource.Unlock()
+
+	// Process lowest-priority-number (highest priority) files last so
+	// their values end up winning on any conflict.
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].priority != files[j].priority {
+			return files[i].priority > files[j].priority
+		}
+		return files[i].filePath > files[j].filePath
+	})
+
+	for _, f := range files {
+		conf, ok := rawConfigs[f.filePath]
+		if !ok {
+			continue
+		}
+
+		events := fSource.compareUpdate(conf, f.filePath)
+		if fSource.watchPool != nil && fSource.watchPool.callback != nil {
+			for _, e := range events {
+				fSource.watchPool.callback.OnEvent(e)
+			}
+		}
+	}
+
+	return nil
+}
+
+//pullFileConfig reads fileName and decodes it with the Parser registered
+//for its file extension, producing the flattened map[string]interface{}
+//the rest of the pipeline expects regardless of source format.
+func (fSource *yamlConfigurationSource) pullFileConfig(fileName string) (map[string]interface{}, error) {
+	return parseFile(fileName)
+}
+
+//fileExt returns the lower-cased extension of fileName, including the
+//leading dot, e.g. "config.YAML" -> ".yaml".
+func fileExt(fileName string) string {
+	return strings.ToLower(filepath.Ext(fileName))
 }
 
 func (fSource *yamlConfigurationSource) GetConfigurations() (map[string]interface{}, error) {
@@ -295,29 +579,6 @@ func (fSource *yamlConfigurationSource) GetConfigurations() (map[string]interfac
 	return configMap, nil
 }
 
-func retrieveItems(prefix string, subItems yaml.MapSlice) map[string]interface{} {
-	if prefix != "" {
-		prefix += "."
-	}
-
-	result := map[string]interface{}{}
-
-	for _, item := range subItems {
-		//If there are sub-items existing
-		_, isSlice := item.Value.(yaml.MapSlice)
-		if isSlice {
-			subResult := retrieveItems(prefix+item.Key.(string), item.Value.(yaml.MapSlice))
-			for k, v := range subResult {
-				result[k] = v
-			}
-		} else {
-			result[prefix+item.Key.(string)] = item.Value
-		}
-	}
-
-	return result
-}
-
 func (fSource *yamlConfigurationSource) GetConfigurationByKey(key string) (interface{}, error) {
 	fSource.Lock()
 	defer fSource.Unlock()
@@ -356,7 +617,7 @@ func (fSource *yamlConfigurationSource) DynamicConfigHandler(callback core.Dynam
 
 	fSource.watchPool = watchPool
 
-	go fSource.watchPool.startWatchPool()
+	fSource.watchPool.startWatchPool()
 
 	return nil
 }
@@ -373,25 +634,43 @@ func newWatchPool(callback core.DynamicConfigCallback, cfgSrc *yamlConfiguration
 	//watch.files = make([]string, 0)
 	watch.fileSource = cfgSrc
 	watch.watcher = watcher
+	watch.debounceInterval = cfgSrc.debounceInterval
+	watch.pending = make(map[string]*time.Timer)
+	watch.symlinkWatch = make(map[string][]string)
 
 	return watch, nil
 }
 
+//SetDebounceInterval overrides the debounce interval, both for a future
+//DynamicConfigHandler call and for an already-running watch pool.
+func (fSource *yamlConfigurationSource) SetDebounceInterval(interval time.Duration) {
+	fSource.debounceInterval = interval
+
+	if fSource.watchPool == nil {
+		return
+	}
+
+	fSource.watchPool.Lock()
+	defer fSource.watchPool.Unlock()
+	fSource.watchPool.debounceInterval = interval
+}
+
+//startWatchPool registers watches on every known file and directory
+//before returning, so callers can rely on the watch pool already
+//observing the filesystem once DynamicConfigHandler returns. Only the
+//blocking event loop runs in its own goroutine.
 func (wth *watch) startWatchPool() {
-	go wth.watchFile()
 	for _, file := range wth.fileSource.files {
-		dir, err := filepath.Abs(filepath.Dir(file.filePath))
-		if err != nil {
-			lager.Logger.Errorf(err, "failed to get Directory info from: %s file.", file.filePath)
-			return
-		}
+		wth.AddWatchFile(file.filePath)
+	}
 
-		err = wth.watcher.Add(dir)
-		if err != nil {
-			lager.Logger.Errorf(err, "add watcher file: %+v fail.", file)
-			return
+	for dir, ds := range wth.fileSource.dirOptions {
+		for _, sub := range wth.fileSource.watchedDirs(dir, ds.opts) {
+			wth.AddWatchDir(sub)
 		}
 	}
+
+	go wth.watchFile()
 }
 
 func (wth *watch) AddWatchFile(filePath string) {
@@ -406,6 +685,155 @@ func (wth *watch) AddWatchFile(filePath string) {
 		lager.Logger.Errorf(err, "add watcher file: %s fail.", filePath)
 		return
 	}
+
+	wth.trackSymlink(dir, filePath)
+}
+
+//trackSymlink records filePath against its literal parent directory dir
+//if filePath resolves through at least one symlink, so a later event in
+//dir - e.g. Kubernetes swapping a ConfigMap's "..data" symlink - can be
+//recognized as something that may have changed filePath's content even
+//though it names a different, sibling path.
+func (wth *watch) trackSymlink(dir, filePath string) {
+	resolved, err := filepath.EvalSymlinks(filePath)
+	if err != nil || resolved == filePath {
+		return
+	}
+
+	wth.Lock()
+	defer wth.Unlock()
+
+	if wth.symlinkWatch == nil {
+		wth.symlinkWatch = make(map[string][]string)
+	}
+
+	for _, existing := range wth.symlinkWatch[dir] {
+		if existing == filePath {
+			return
+		}
+	}
+	wth.symlinkWatch[dir] = append(wth.symlinkWatch[dir], filePath)
+}
+
+//reloadSymlinkedFiles schedules a reload for every symlinked file
+//tracked under eventPath's directory, and reports whether any such file
+//was found. Callers use the return value to tell a ConfigMap-style
+//"..data" swap - which fires its fsnotify event against "..data" itself,
+//not against the mounted file - apart from a genuinely unrelated path.
+func (wth *watch) reloadSymlinkedFiles(eventPath string) bool {
+	dir := filepath.Dir(eventPath)
+
+	wth.RLock()
+	files := append([]string(nil), wth.symlinkWatch[dir]...)
+	wth.RUnlock()
+
+	if len(files) == 0 {
+		return false
+	}
+
+	for _, filePath := range files {
+		wth.scheduleReload(filePath)
+	}
+
+	return true
+}
+
+//AddWatchDir registers dir itself (not its parent) with fsnotify, used
+//for directory file sources so newly created files - and, when
+//Recursive is set, newly created nested directories - are picked up.
+func (wth *watch) AddWatchDir(dir string) {
+	err := wth.watcher.Add(dir)
+	if err != nil {
+		lager.Logger.Errorf(err, "add watcher directory: %s fail.", dir)
+	}
+}
+
+//registerDiscoveredFile brings a file that just appeared under a watched
+//directory source into the same tracked state a file present at
+//AddFileSource time would have: registered in files with priority,
+//parsed, and diffed so its initial Create events reach the callback
+//instead of being silently dropped by compareUpdate, which bails out for
+//any path it doesn't already recognize.
+func (wth *watch) registerDiscoveredFile(filePath string, priority uint32) {
+	fs, err := os.Open(filePath)
+	if err != nil {
+		lager.Logger.Errorf(err, "error in file open for %s file", filePath)
+		return
+	}
+	defer fs.Close()
+
+	if err := wth.fileSource.handleFile(fs, priority); err != nil {
+		lager.Logger.Errorf(err, "error processing %s file source handler with error : %s ", filePath, err.Error())
+	}
+}
+
+//scheduleReload (re)starts the debounce timer for path. Repeated calls
+//for the same path before the interval expires collapse into a single
+//reload once events stop arriving.
+func (wth *watch) scheduleReload(path string) {
+	wth.Lock()
+	defer wth.Unlock()
+
+	if timer, ok := wth.pending[path]; ok {
+		timer.Stop()
+	}
+
+	wth.pending[path] = time.AfterFunc(wth.debounceInterval, func() {
+		wth.Lock()
+		delete(wth.pending, path)
+		wth.Unlock()
+
+		wth.reload(path)
+	})
+}
+
+//reload re-parses path and dispatches any resulting events to the
+//callback. Called once the debounce timer for path expires.
+func (wth *watch) reload(path string) {
+	newConf, err := wth.fileSource.pullFileConfig(path)
+	if err != nil {
+		lager.Logger.Warnf("reload of [%s] failed, %s", path, err)
+		return
+	}
+
+	wth.fileSource.setRawConfig(path, newConf)
+
+	events := wth.fileSource.compareUpdate(newConf, path)
+	lager.Logger.Debugf("Event generated events", events)
+	for _, e := range events {
+		wth.callback.OnEvent(e)
+	}
+}
+
+const (
+	//reappearRetries is how many times awaitReappear re-stats a path
+	//that just disappeared before giving up on it.
+	reappearRetries = 5
+	//reappearBackoff is the delay between each of those retries.
+	reappearBackoff = 50 * time.Millisecond
+)
+
+//awaitReappear handles the rename-over-write / remove-then-recreate
+//pattern used by atomic config saves: the path is briefly gone, then
+//reappears - possibly as a new inode, or as a symlink repointed at a
+//new target, as Kubernetes does when it swaps a ConfigMap volume's
+//"..data" symlink. It retries for a short window before treating the
+//path as gone for good, matching the previous "stop watching" behavior.
+func (wth *watch) awaitReappear(path string) {
+	go func() {
+		for attempt := 0; attempt < reappearRetries; attempt++ {
+			time.Sleep(reappearBackoff)
+
+			if _, err := os.Stat(path); err == nil {
+				wth.AddWatchFile(path)
+				wth.scheduleReload(path)
+				return
+			}
+		}
+
+		lager.Logger.Warnf("[%s] did not reappear after %d retries; no longer watching it",
+			path, reappearRetries)
+	}()
 }
 
 func (wth *watch) watchFile() {
@@ -425,44 +853,71 @@ func (wth *watch) watchFile() {
 			}
 			lager.Logger.Debugf("the file %s is change for %s. reload it.", event.Name, event.Op.String())
 
-			if event.Op == fsnotify.Remove {
-				lager.Logger.Warnf("the file change mode: %s. So stop watching file",
-					event.String())
+			if !wth.fileSource.isFileSrcExist(event.Name) && wth.reloadSymlinkedFiles(event.Name) {
+				// a sibling entry in a symlinked tracked file's own
+				// directory changed - e.g. Kubernetes swapping the
+				// "..data" symlink a ConfigMap-projected file resolves
+				// through. Reload every symlinked file tracked there
+				// instead of treating event.Name itself, which is
+				// otherwise unrelated and possibly unparseable, as a
+				// reload target.
 				continue
 			}
 
-			if event.Op == fsnotify.Rename {
-				wth.watcher.Remove(event.Name)
-				// check existence of file
-				_, err := os.Open(event.Name)
-				if os.IsNotExist(err) {
-					lager.Logger.Warnf("[%s] file does not exist so not able to watch further", event.Name, err)
-				} else {
-					wth.AddWatchFile(event.Name)
+			var dirPriority uint32
+			var underDir bool
+
+			if opts, priority, _, found := wth.fileSource.dirOptionsFor(event.Name); found {
+				underDir = true
+				dirPriority = priority
+
+				if info, statErr := os.Stat(event.Name); statErr == nil && info.IsDir() {
+					if opts.Recursive && event.Op&fsnotify.Create != 0 && opts.allowsDir(filepath.Base(event.Name)) {
+						wth.fileSource.setDirOptions(event.Name, priority, opts)
+						wth.AddWatchDir(event.Name)
+					}
+					continue
 				}
 
-				continue
+				if !opts.allowsExt(event.Name) {
+					if !opts.IgnoreWarn {
+						lager.Logger.Warnf("ignoring %s event for filtered-out path [%s]", event.Op.String(), event.Name)
+					}
+					continue
+				}
 			}
 
-			yamlContent, err := ioutil.ReadFile(event.Name)
-			if err != nil {
-				lager.Logger.Error("yaml parsing error ", err)
+			if event.Op == fsnotify.Chmod {
+				// permission/metadata-only change, nothing to reload
 				continue
 			}
-			ss := yaml.MapSlice{}
-			err = yaml.Unmarshal([]byte(yamlContent), &ss)
-			if err != nil {
-				lager.Logger.Warnf("unmarshaling failed may be due to invalid file data format", err)
+
+			if event.Op == fsnotify.Remove || event.Op == fsnotify.Rename {
+				if event.Op == fsnotify.Rename {
+					wth.watcher.Remove(event.Name)
+				}
+
+				// Rename-over and Remove both precede an atomic save
+				// (editors, and Kubernetes ConfigMap volumes swapping
+				// their "..data" symlink): the path is briefly gone and
+				// then reappears, possibly through a new symlink target
+				// or inode. awaitReappear retries before giving up.
+				wth.awaitReappear(event.Name)
 				continue
 			}
 
-			newConf := retrieveItems("", ss)
-			events := wth.fileSource.compareUpdate(newConf, event.Name)
-			lager.Logger.Debugf("Event generated events", events)
-			for _, e := range events {
-				wth.callback.OnEvent(e)
+			if underDir && !wth.fileSource.isFileSrcExist(event.Name) {
+				// a brand-new file appeared under a watched directory
+				// source: route it through handleFile/handlePriority so
+				// it is actually registered (files, priority, raw
+				// config), not just diffed against a priority that was
+				// never assigned to it.
+				wth.registerDiscoveredFile(event.Name, dirPriority)
+				continue
 			}
 
+			wth.scheduleReload(event.Name)
+
 		case err := <-wth.watcher.Errors:
 			lager.Logger.Debugf("watch file error:", err)
 			return
@@ -536,6 +991,7 @@ func (fSource *yamlConfigurationSource) compareUpdate(newconf map[string]interfa
 
 				} else if filePathPriority < priority { // lower the vale higher is the priority
 					confInfo.Value = newConfValue
+					confInfo.FilePath = filePath
 					fileConfs[key] = confInfo
 					events = append(events, &core.Event{EventSource: FileConfigSourceConst,
 						Key: key, EventType: core.Update, Value: newConfValue})
@@ -550,14 +1006,14 @@ func (fSource *yamlConfigurationSource) compareUpdate(newconf map[string]interfa
 	}
 
 	// create add/create new config
-	fileConfs = fSource.addOrCreateConf(fileConfs, newconf, events, filePath)
+	fileConfs, events = fSource.addOrCreateConf(fileConfs, newconf, events, filePath)
 	fSource.Configurations = fileConfs
 
 	return events
 }
 
 func (fSource *yamlConfigurationSource) addOrCreateConf(fileConfs map[string]*ConfigInfo, newconf map[string]interface{},
-	events []*core.Event, filePath string) map[string]*ConfigInfo {
+	events []*core.Event, filePath string) (map[string]*ConfigInfo, []*core.Event) {
 	for key, value := range newconf {
 		handled := false
 
@@ -576,7 +1032,7 @@ func (fSource *yamlConfigurationSource) addOrCreateConf(fileConfs map[string]*Co
 		}
 	}
 
-	return fileConfs
+	return fileConfs, events
 }
 
 //func generateKey(key, filepath string) string {
@@ -592,7 +1048,7 @@ func (fSource *yamlConfigurationSource) Cleanup() error {
 	fSource.filelock.Lock()
 	defer fSource.filelock.Unlock()
 
-	if fileConfigSource == nil || fSource == nil {
+	if fSource == nil {
 		return nil
 	}
 
@@ -607,6 +1063,8 @@ func (fSource *yamlConfigurationSource) Cleanup() error {
 	}
 	fSource.Configurations = nil
 	fSource.files = make([]file, 0)
+	fSource.rawConfigs = make(map[string]map[string]interface{})
+	fSource.dirOptions = make(map[string]dirSource)
 	return nil
 }
 
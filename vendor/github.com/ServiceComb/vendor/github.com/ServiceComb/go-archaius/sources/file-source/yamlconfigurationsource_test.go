@@ -0,0 +1,436 @@
+/*
+ * Copyright 2017 Huawei Technologies Co., Ltd
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package filesource
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ServiceComb/go-archaius/core"
+)
+
+//recordingCallback implements core.DynamicConfigCallback, collecting
+//every event fired so tests can assert on them.
+type recordingCallback struct {
+	mu     sync.Mutex
+	events []*core.Event
+}
+
+func (c *recordingCallback) OnEvent(event *core.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.events = append(c.events, event)
+}
+
+func (c *recordingCallback) valueOf(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := len(c.events) - 1; i >= 0; i-- {
+		if c.events[i].Key == key {
+			return c.events[i].Value, true
+		}
+	}
+
+	return nil, false
+}
+
+func (c *recordingCallback) countOf(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := 0
+	for _, event := range c.events {
+		if event.Key == key {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestNewFileSourceIndependentInstances(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesource-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileA := filepath.Join(dir, "a.yaml")
+	fileB := filepath.Join(dir, "b.yaml")
+
+	if err := ioutil.WriteFile(fileA, []byte("keyA: valueA\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", fileA, err)
+	}
+	if err := ioutil.WriteFile(fileB, []byte("keyB: valueB\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", fileB, err)
+	}
+
+	sourceOne := NewFileSource()
+	if err := sourceOne.AddFileSource(fileA, 10); err != nil {
+		t.Fatalf("AddFileSource failed for sourceOne: %s", err)
+	}
+
+	sourceTwo := NewFileSource()
+	if err := sourceTwo.AddFileSource(fileB, 20); err != nil {
+		t.Fatalf("AddFileSource failed for sourceTwo: %s", err)
+	}
+
+	confOne, err := sourceOne.GetConfigurations()
+	if err != nil {
+		t.Fatalf("GetConfigurations failed for sourceOne: %s", err)
+	}
+	if _, ok := confOne["keyA"]; !ok {
+		t.Errorf("sourceOne missing its own key keyA")
+	}
+	if _, ok := confOne["keyB"]; ok {
+		t.Errorf("sourceOne was clobbered with sourceTwo's keyB")
+	}
+
+	confTwo, err := sourceTwo.GetConfigurations()
+	if err != nil {
+		t.Fatalf("GetConfigurations failed for sourceTwo: %s", err)
+	}
+	if _, ok := confTwo["keyB"]; !ok {
+		t.Errorf("sourceTwo missing its own key keyB")
+	}
+	if _, ok := confTwo["keyA"]; ok {
+		t.Errorf("sourceTwo was clobbered with sourceOne's keyA")
+	}
+}
+
+func TestRecursiveDirectorySourcePicksUpNewFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "filesource-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(root)
+
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatalf("failed to create %s: %s", sub, err)
+	}
+
+	existing := filepath.Join(sub, "existing.yaml")
+	if err := ioutil.WriteFile(existing, []byte("existing: yes\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", existing, err)
+	}
+	// .properties files are excluded by IncludeExt below; this must never
+	// surface as a configuration key.
+	if err := ioutil.WriteFile(filepath.Join(sub, "ignored.properties"), []byte("ignored=yes\n"), 0644); err != nil {
+		t.Fatalf("failed to write ignored.properties: %s", err)
+	}
+
+	source := NewFileSource(WithDebounceInterval(20 * time.Millisecond))
+	opts := FileSourceOptions{
+		Recursive:  true,
+		IncludeExt: []string{".yaml"},
+	}
+	if err := source.AddFileSource(root, 5, opts); err != nil {
+		t.Fatalf("AddFileSource failed: %s", err)
+	}
+
+	conf, err := source.GetConfigurations()
+	if err != nil {
+		t.Fatalf("GetConfigurations failed: %s", err)
+	}
+	if _, ok := conf["existing"]; !ok {
+		t.Fatalf("expected pre-existing nested key to be picked up, got %v", conf)
+	}
+	if _, ok := conf["ignored"]; ok {
+		t.Fatalf("expected .properties file to be excluded by IncludeExt, got %v", conf)
+	}
+
+	callback := &recordingCallback{}
+	if err := source.DynamicConfigHandler(callback); err != nil {
+		t.Fatalf("DynamicConfigHandler failed: %s", err)
+	}
+
+	created := filepath.Join(sub, "created.yaml")
+	if err := ioutil.WriteFile(created, []byte("created: \"yes\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", created, err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, ok := callback.valueOf("created"); ok && value == "yes" {
+			conf, err := source.GetConfigurations()
+			if err != nil {
+				t.Fatalf("GetConfigurations failed: %s", err)
+			}
+			if conf["created"] != "yes" {
+				t.Fatalf("expected GetConfigurations to reflect the new file, got %v", conf["created"])
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("did not observe the newly created nested file within the deadline")
+}
+
+func TestReprioritizeChangesWinner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesource-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	low := filepath.Join(dir, "low.yaml")
+	high := filepath.Join(dir, "high.yaml")
+
+	if err := ioutil.WriteFile(low, []byte("shared: fromLow\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", low, err)
+	}
+	if err := ioutil.WriteFile(high, []byte("shared: fromHigh\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", high, err)
+	}
+
+	source := NewFileSource()
+	// lower priority number wins, so "low" starts out as the winner
+	if err := source.AddFileSource(low, 1); err != nil {
+		t.Fatalf("AddFileSource failed for low: %s", err)
+	}
+	if err := source.AddFileSource(high, 10); err != nil {
+		t.Fatalf("AddFileSource failed for high: %s", err)
+	}
+
+	merged := source.GetMergedConfigurations()
+	if merged["shared"] != "fromLow" {
+		t.Fatalf("expected shared=fromLow before reprioritizing, got %v", merged["shared"])
+	}
+
+	// give "high" the lower (winning) priority number instead
+	if err := source.Reprioritize(high, 0); err != nil {
+		t.Fatalf("Reprioritize failed: %s", err)
+	}
+
+	merged = source.GetMergedConfigurations()
+	if merged["shared"] != "fromHigh" {
+		t.Fatalf("expected shared=fromHigh after reprioritizing, got %v", merged["shared"])
+	}
+}
+
+func TestReprioritizeTwiceDoesNotResurrectStaleWinner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesource-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "p.yaml")
+	q := filepath.Join(dir, "q.yaml")
+	r := filepath.Join(dir, "r.yaml")
+
+	if err := ioutil.WriteFile(p, []byte("k: p\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", p, err)
+	}
+	if err := ioutil.WriteFile(q, []byte("k: q\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", q, err)
+	}
+	if err := ioutil.WriteFile(r, []byte("k: r\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", r, err)
+	}
+
+	source := NewFileSource()
+	// p starts out as the winner (lowest priority number).
+	if err := source.AddFileSource(p, 1); err != nil {
+		t.Fatalf("AddFileSource failed for p: %s", err)
+	}
+	if err := source.AddFileSource(q, 2); err != nil {
+		t.Fatalf("AddFileSource failed for q: %s", err)
+	}
+	if err := source.AddFileSource(r, 3); err != nil {
+		t.Fatalf("AddFileSource failed for r: %s", err)
+	}
+
+	callback := &recordingCallback{}
+	if err := source.DynamicConfigHandler(callback); err != nil {
+		t.Fatalf("DynamicConfigHandler failed: %s", err)
+	}
+
+	// hand the win to q (priority 0 beats p's 1).
+	if err := source.Reprioritize(q, 0); err != nil {
+		t.Fatalf("Reprioritize(q) failed: %s", err)
+	}
+
+	if value, ok := callback.valueOf("k"); !ok || value != "q" {
+		t.Fatalf("expected k=q after reprioritizing q, got %v (ok=%v)", value, ok)
+	}
+
+	// r's new priority (2) is worse than both q's (0) and p's (1): this must
+	// not disturb the current winner, q. It only regresses if compareUpdate
+	// is still consulting a stale ConfigInfo.FilePath left over from the
+	// previous Reprioritize call to look up "the current winner's priority".
+	if err := source.Reprioritize(r, 2); err != nil {
+		t.Fatalf("Reprioritize(r) failed: %s", err)
+	}
+
+	conf, err := source.GetConfigurations()
+	if err != nil {
+		t.Fatalf("GetConfigurations failed: %s", err)
+	}
+	if conf["k"] != "q" {
+		t.Fatalf("expected k=q to survive reprioritizing r, got %v", conf["k"])
+	}
+
+	callback.mu.Lock()
+	var kEvents []interface{}
+	for _, e := range callback.events {
+		if e.Key == "k" {
+			kEvents = append(kEvents, e.Value)
+		}
+	}
+	callback.mu.Unlock()
+
+	if len(kEvents) != 1 {
+		t.Fatalf("expected exactly one callback event for key k (from reprioritizing q), got %v", kEvents)
+	}
+}
+
+//TestConfigMapStyleSymlinkSwap simulates how Kubernetes projects a
+//ConfigMap volume: the mounted file is a symlink to "..data/<key>", and
+//"..data" is itself a symlink to a timestamped directory that gets
+//swapped atomically (symlink-and-rename) on every update. fsnotify never
+//fires an event for the mounted file path itself - only for "..data" -
+//so the watcher has to follow the symlink chain to notice the change.
+func TestConfigMapStyleSymlinkSwap(t *testing.T) {
+	mountDir, err := ioutil.TempDir("", "filesource-configmap-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(mountDir)
+
+	dataV1 := filepath.Join(mountDir, "..data_v1")
+	if err := os.Mkdir(dataV1, 0755); err != nil {
+		t.Fatalf("failed to create %s: %s", dataV1, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataV1, "app.yaml"), []byte("greeting: hello\n"), 0644); err != nil {
+		t.Fatalf("failed to write initial app.yaml: %s", err)
+	}
+
+	dataLink := filepath.Join(mountDir, "..data")
+	if err := os.Symlink(dataV1, dataLink); err != nil {
+		t.Fatalf("failed to create ..data symlink: %s", err)
+	}
+
+	mountedFile := filepath.Join(mountDir, "app.yaml")
+	if err := os.Symlink(filepath.Join(dataLink, "app.yaml"), mountedFile); err != nil {
+		t.Fatalf("failed to create app.yaml symlink: %s", err)
+	}
+
+	source := NewFileSource(WithDebounceInterval(20 * time.Millisecond))
+	if err := source.AddFileSource(mountedFile, 0); err != nil {
+		t.Fatalf("AddFileSource failed: %s", err)
+	}
+
+	callback := &recordingCallback{}
+	if err := source.DynamicConfigHandler(callback); err != nil {
+		t.Fatalf("DynamicConfigHandler failed: %s", err)
+	}
+
+	dataV2 := filepath.Join(mountDir, "..data_v2")
+	if err := os.Mkdir(dataV2, 0755); err != nil {
+		t.Fatalf("failed to create %s: %s", dataV2, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dataV2, "app.yaml"), []byte("greeting: goodbye\n"), 0644); err != nil {
+		t.Fatalf("failed to write updated app.yaml: %s", err)
+	}
+
+	// atomically repoint ..data at the new directory, exactly as the
+	// kubelet does on a ConfigMap update
+	tmpLink := filepath.Join(mountDir, "..data_tmp")
+	if err := os.Symlink(dataV2, tmpLink); err != nil {
+		t.Fatalf("failed to create replacement ..data symlink: %s", err)
+	}
+	if err := os.Rename(tmpLink, dataLink); err != nil {
+		t.Fatalf("failed to swap ..data symlink: %s", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, ok := callback.valueOf("greeting"); ok && value == "goodbye" {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("did not observe the ..data symlink swap within the deadline")
+}
+
+//TestDebounceCoalescesRapidWrites verifies that a burst of rapid writes to
+//the same file, all landing within the debounce window, collapses into a
+//single reload/callback instead of one per fsnotify event.
+func TestDebounceCoalescesRapidWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "filesource-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	target := filepath.Join(dir, "app.yaml")
+	if err := ioutil.WriteFile(target, []byte("value: 0\n"), 0644); err != nil {
+		t.Fatalf("failed to write %s: %s", target, err)
+	}
+
+	const debounceInterval = 300 * time.Millisecond
+	source := NewFileSource(WithDebounceInterval(debounceInterval))
+	if err := source.AddFileSource(target, 5, FileSourceOptions{}); err != nil {
+		t.Fatalf("AddFileSource failed: %s", err)
+	}
+
+	callback := &recordingCallback{}
+	if err := source.DynamicConfigHandler(callback); err != nil {
+		t.Fatalf("DynamicConfigHandler failed: %s", err)
+	}
+
+	// Fire several writes in quick succession, well inside the debounce
+	// window, so every fsnotify event but the last should be swallowed.
+	const writes = 5
+	for i := 1; i <= writes; i++ {
+		body := []byte(fmt.Sprintf("value: %d\n", i))
+		if err := ioutil.WriteFile(target, body, 0644); err != nil {
+			t.Fatalf("failed to write %s: %s", target, err)
+		}
+		time.Sleep(debounceInterval / 10)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if value, ok := callback.valueOf("value"); ok && value == writes {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	value, ok := callback.valueOf("value")
+	if !ok || value != writes {
+		t.Fatalf("did not observe the final write within the deadline, got %v", value)
+	}
+
+	// Give any spurious extra reloads a chance to land before counting.
+	time.Sleep(debounceInterval)
+
+	if count := callback.countOf("value"); count != 1 {
+		t.Fatalf("expected the debounced writes to coalesce into 1 callback event, got %d", count)
+	}
+}